@@ -2,14 +2,18 @@ package assets
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
 	"sync"
 	"text/template"
 
 	"github.com/gu-io/gu/assets/data"
 	"github.com/influx6/moz/gen"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -26,28 +30,78 @@ type WriteDirective struct {
 	Writer        io.WriterTo
 	OriginPath    string
 	OriginAbsPath string
+	Codec         CompressionCodec
+
+	// Integrity is the SRI digest (e.g. "sha256-...") of the directive's
+	// content, carried through from Resource.Fingerprint when the
+	// directive was produced via Resource.Directive. Empty otherwise.
+	Integrity string
+}
+
+// CodecName returns the name of the codec directive.Read compresses with
+// (Gzip's if Codec is unset), for recording alongside the compressed blob so
+// a runtime loader knows which decompressor to pair it with.
+func (directive WriteDirective) CodecName() string {
+	if directive.Codec == nil {
+		return Gzip.Name()
+	}
+
+	return directive.Codec.Name()
 }
 
 // Read will copy directives writer into a content buffer and returns the giving string
-// representation of that data, content will be gzipped.
+// representation of that data, compressed with directive.Codec (Gzip if unset).
 func (directive WriteDirective) Read() (string, error) {
+	codec := directive.Codec
+	if codec == nil {
+		codec = Gzip
+	}
+
 	buffer := bufferPool.Get().(*bytes.Buffer)
 
 	defer buffer.Reset()
 	defer bufferPool.Put(buffer)
 
-	if _, err := directive.Writer.WriteTo(gzip.NewWriter(buffer)); err != nil && err != io.EOF {
+	writer := codec.NewWriter(buffer)
+
+	if _, err := directive.Writer.WriteTo(writer); err != nil && err != io.EOF {
+		writer.Close()
+		return buffer.String(), err
+	}
+
+	if err := writer.Close(); err != nil {
 		return buffer.String(), err
 	}
 
 	return buffer.String(), nil
 }
 
+// Decode reverses Read: it decompresses blob with the CompressionCodec named
+// by codecName, resolved via CodecByName. This is the pairing a generated
+// loader performs once it only has a blob and the codec name Compile
+// recorded alongside it (directive.CodecName()), rather than the live
+// CompressionCodec value.
+func (directive WriteDirective) Decode(blob string, codecName string) ([]byte, error) {
+	codec, err := CodecByName(codecName)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := codec.NewReader(strings.NewReader(blob))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(reader)
+}
+
 // Packer exposes a interface which exposes methods for validating the type of files
 // it supports and a method to appropriately pack the FileStatments as desired
-// into the given endpoint directory.
+// into the given endpoint directory. ctx is cancelled if a sibling Packer
+// running in the same Build fails, and should be honored by any Packer that
+// does its own I/O or shells out to external tools.
 type Packer interface {
-	Pack(files []FileStatement, dir DirStatement) ([]WriteDirective, error)
+	Pack(ctx context.Context, files []FileStatement, dir DirStatement) ([]WriteDirective, error)
 }
 
 // Webpack defines the core structure for handling bundling of different assets
@@ -55,6 +109,11 @@ type Packer interface {
 type Webpack struct {
 	defaultPacker Packer
 	packers       map[string]Packer
+	mounts        []Mount
+	codecs        map[string]CompressionCodec
+
+	mu    sync.Mutex
+	built map[string][]WriteDirective
 }
 
 // New returns a new instance of the Webpack.
@@ -62,6 +121,7 @@ func New(defaultPacker Packer) *Webpack {
 	return &Webpack{
 		defaultPacker: defaultPacker,
 		packers:       make(map[string]Packer, 0),
+		codecs:        make(map[string]CompressionCodec, 0),
 	}
 }
 
@@ -70,37 +130,167 @@ func (w *Webpack) Register(ext string, packer Packer) {
 	w.packers[ext] = packer
 }
 
+// SetCodec selects the CompressionCodec used to compress WriteDirectives
+// produced for files with the given extension. Extensions with no codec
+// set fall back to Gzip.
+func (w *Webpack) SetCodec(ext string, codec CompressionCodec) {
+	w.codecs[ext] = codec
+}
+
+// codecFor returns the codec registered for ext, or Gzip if none was set.
+func (w *Webpack) codecFor(ext string) CompressionCodec {
+	if codec, ok := w.codecs[ext]; ok {
+		return codec
+	}
+
+	return Gzip
+}
+
 // Build runs through the directory pull all files and runs them through the
 // packers to service each files by extension and returns a slice of all
-// WriteDirective for final processing.
+// WriteDirective for final processing. Packers run concurrently, one
+// goroutine per extension, bounded to GOMAXPROCS at a time; the first
+// Packer to fail cancels the rest via their context. The result also
+// replaces w's last-built set, so later calls to w.Built reflect it.
 func (w *Webpack) Build(dir string, doGoSources bool) (map[string][]WriteDirective, error) {
-	statement, err := GetDirStatement(dir, doGoSources)
+	statement, err := w.dirStatement(dir, doGoSources)
 	if err != nil {
 		return nil, err
 	}
 
-	var wd map[string][]WriteDirective
+	exts := make([]string, 0, len(statement.FilesByExt))
+	for ext := range statement.FilesByExt {
+		exts = append(exts, ext)
+	}
 
-	for ext, fileStatement := range statement.FilesByExt {
-		packer, ok := w.packers[ext]
-		if !ok && w.defaultPacker == nil {
-			return wd, fmt.Errorf("No Packer provided to handle files with %q extension", ext)
-		}
+	wd, err := w.packExts(context.Background(), statement, exts)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	w.built = wd
+	w.mu.Unlock()
 
-		var derr error
-		var directives []WriteDirective
+	return wd, nil
+}
 
-		if w.defaultPacker != nil && !ok {
-			directives, derr = w.defaultPacker.Pack(fileStatement, statement)
-		} else {
-			directives, derr = packer.Pack(fileStatement, statement)
+// Rebuild re-walks dir (mounts still need resolving, since a changed path
+// may have added or removed files) but, unlike Build, only re-runs the
+// Packers registered for exts, merging their fresh WriteDirectives into w's
+// last-built set rather than repacking every extension. It's what
+// Webpack.Serve uses to repack incrementally after a filesystem change, and
+// returns the merged set.
+func (w *Webpack) Rebuild(dir string, doGoSources bool, exts []string) (map[string][]WriteDirective, error) {
+	statement, err := w.dirStatement(dir, doGoSources)
+	if err != nil {
+		return nil, err
+	}
+
+	fresh, err := w.packExts(context.Background(), statement, exts)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	merged := make(map[string][]WriteDirective, len(w.built)+len(fresh))
+	for ext, directives := range w.built {
+		merged[ext] = directives
+	}
+	for ext, directives := range fresh {
+		merged[ext] = directives
+	}
+	w.built = merged
+	w.mu.Unlock()
+
+	return merged, nil
+}
+
+// Built returns the WriteDirectives produced by the most recent Build or
+// Rebuild. The returned map is w's own record and must be treated as
+// read-only: Build and Rebuild always install a fresh map rather than
+// mutating this one, so it's safe to keep and range over even while a
+// later rebuild runs concurrently.
+func (w *Webpack) Built() map[string][]WriteDirective {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.built
+}
+
+// dirStatement overlays w's mounts onto dir and walks the result, as the
+// first step shared by Build and Rebuild.
+func (w *Webpack) dirStatement(dir string, doGoSources bool) (DirStatement, error) {
+	overlay, err := w.overlayDir(dir)
+	if err != nil {
+		return DirStatement{}, err
+	}
+
+	return GetDirStatement(overlay, doGoSources)
+}
+
+// packExts runs the Packer registered for each extension in exts against
+// statement, concurrently and bounded to GOMAXPROCS at a time, and returns
+// the resulting WriteDirectives keyed by extension. The first Packer to
+// fail cancels the rest via ctx.
+func (w *Webpack) packExts(ctx context.Context, statement DirStatement, exts []string) (map[string][]WriteDirective, error) {
+	packers := make(map[string]Packer, len(exts))
+
+	for _, ext := range exts {
+		packer, ok := w.packers[ext]
+		if !ok {
+			packer = w.defaultPacker
 		}
 
-		if derr != nil {
-			return wd, err
+		if packer == nil {
+			return nil, fmt.Errorf("No Packer provided to handle files with %q extension", ext)
 		}
 
-		wd[ext] = directives
+		packers[ext] = packer
+	}
+
+	wd := make(map[string][]WriteDirective, len(exts))
+
+	g, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+
+	var mu sync.Mutex
+
+	for _, ext := range exts {
+		ext := ext
+		fileStatement := statement.FilesByExt[ext]
+		packer := packers[ext]
+
+		g.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			directives, err := packer.Pack(ctx, fileStatement, statement)
+			if err != nil {
+				return err
+			}
+
+			codec := w.codecFor(ext)
+			for i := range directives {
+				if directives[i].Codec == nil {
+					directives[i].Codec = codec
+				}
+			}
+
+			mu.Lock()
+			wd[ext] = directives
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 
 	return wd, nil
@@ -109,7 +299,12 @@ func (w *Webpack) Build(dir string, doGoSources bool) (map[string][]WriteDirecti
 // Compile returns a io.WriterTo which contains a complete source of all assets
 // generated and stored inside a io.WriteTo which will contain the go source excluding
 // the package declaration so has to allow you write the contents into the package
-// you wish.
+// you wish. Each Directives entry still carries its directive.CodecName(); it is
+// packed.tml's responsibility to record that name alongside the compressed blob
+// and, on load, resolve it back to a CompressionCodec with CodecByName the way
+// WriteDirective.Decode does, so that a Webpack mixing Gzip, Brotli and Zstd
+// across extensions round-trips correctly. packed.tml is generated separately
+// (see the data package) and is not part of this change.
 func (w *Webpack) Compile(dir string, doGoSources bool) (io.WriterTo, error) {
 	directives, err := w.Build(dir, doGoSources)
 	if err != nil {
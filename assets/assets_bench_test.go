@@ -0,0 +1,61 @@
+package assets
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// noopPacker is a Packer that produces one WriteDirective per file without
+// doing any real transform work, isolating Build's own overhead (walking,
+// scheduling, codec assignment) from whatever a real Packer does.
+type noopPacker struct{}
+
+func (noopPacker) Pack(ctx context.Context, files []FileStatement, dir DirStatement) ([]WriteDirective, error) {
+	return make([]WriteDirective, len(files)), nil
+}
+
+// buildSyntheticTree writes n small files with the given extension into a
+// fresh temp directory, modeled on the site-building benchmarks used by
+// comparable static-site tools to measure Build's scaling.
+func buildSyntheticTree(b *testing.B, n int, ext string) string {
+	dir, err := ioutil.TempDir("", "gu-assets-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file-%d%s", i, ext))
+		if err := ioutil.WriteFile(path, []byte("content"), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func benchmarkBuild(b *testing.B, n int) {
+	dir := buildSyntheticTree(b, n, ".txt")
+	defer os.RemoveAll(dir)
+
+	w := New(noopPacker{})
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Build(dir, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBuild_1kFiles(b *testing.B) {
+	benchmarkBuild(b, 1000)
+}
+
+func BenchmarkBuild_10kFiles(b *testing.B) {
+	benchmarkBuild(b, 10000)
+}
@@ -0,0 +1,132 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionCodec names and constructs the compressor used to shrink a
+// WriteDirective's content before it is embedded as a generated blob.
+type CompressionCodec interface {
+	// Name identifies the codec in generated sources, so the runtime loader
+	// knows which decompressor to pair with a given blob.
+	Name() string
+
+	// NewWriter wraps w, compressing everything written to the result.
+	NewWriter(w io.Writer) io.WriteCloser
+
+	// NewReader wraps r, decompressing what NewWriter produced. Generated
+	// loaders pick the codec to use here from the Name recorded alongside
+	// each blob.
+	NewReader(r io.Reader) (io.Reader, error)
+}
+
+// gzipCodec compresses with compress/gzip. It is Webpack's default codec,
+// kept for backward compatibility with existing generated sources.
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) NewWriter(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+
+func (gzipCodec) NewReader(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+
+// brotliCodec compresses with andybalholm/brotli, typically smaller than
+// gzip at a comparable compression level.
+type brotliCodec struct{}
+
+func (brotliCodec) Name() string { return "brotli" }
+
+func (brotliCodec) NewWriter(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+
+func (brotliCodec) NewReader(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }
+
+// zstdCodec compresses with klauspost/compress/zstd.
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string { return "zstd" }
+
+func (zstdCodec) NewWriter(w io.Writer) io.WriteCloser {
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		// Only invalid options cause NewWriter to fail; none are set here.
+		panic(err)
+	}
+
+	return enc
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.Reader, error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// zstd.Decoder.Read never returns until the caller also calls Close, so
+	// buffer eagerly into a plain io.Reader the loader can treat like any
+	// other codec's.
+	defer dec.Close()
+
+	content, err := ioutil.ReadAll(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(content), nil
+}
+
+// identityCodec performs no compression, for content that is already
+// compressed (e.g. pre-minified images) or too small to benefit.
+type identityCodec struct{}
+
+func (identityCodec) Name() string { return "identity" }
+
+func (identityCodec) NewWriter(w io.Writer) io.WriteCloser { return nopWriteCloser{w} }
+
+func (identityCodec) NewReader(r io.Reader) (io.Reader, error) { return r, nil }
+
+// nopWriteCloser adapts an io.Writer into an io.WriteCloser whose Close is a
+// no-op, for codecs (identity) that don't need to flush or finalize.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Gzip, Brotli, Zstd and Identity are the built-in CompressionCodec values
+// Webpack.SetCodec accepts.
+var (
+	Gzip     CompressionCodec = gzipCodec{}
+	Brotli   CompressionCodec = brotliCodec{}
+	Zstd     CompressionCodec = zstdCodec{}
+	Identity CompressionCodec = identityCodec{}
+)
+
+// builtinCodecs maps a codec's Name() back to its CompressionCodec, so a
+// generated loader that only has the name recorded alongside a blob (see
+// Webpack.Compile) can recover the matching decompressor.
+var builtinCodecs = map[string]CompressionCodec{
+	Gzip.Name():     Gzip,
+	Brotli.Name():   Brotli,
+	Zstd.Name():     Zstd,
+	Identity.Name(): Identity,
+}
+
+// CodecByName returns the built-in CompressionCodec registered under name.
+// A blob's codec is identified by name (directive.CodecName()); a loader
+// that only has that name calls CodecByName to turn it back into something
+// it can decompress with.
+func CodecByName(name string) (CompressionCodec, error) {
+	codec, ok := builtinCodecs[name]
+	if !ok {
+		return nil, fmt.Errorf("assets: no CompressionCodec registered for codec %q", name)
+	}
+
+	return codec, nil
+}
@@ -0,0 +1,70 @@
+package assets
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestWriteDirectiveCodecRoundTrip exercises the pairing a generated loader
+// relies on: Read compresses with whatever codec is set, CodecName records
+// which one, and Decode (via CodecByName) must recover the original bytes
+// for every built-in codec.
+func TestWriteDirectiveCodecRoundTrip(t *testing.T) {
+	const content = "the quick brown fox jumps over the lazy dog"
+
+	for _, codec := range []CompressionCodec{Gzip, Brotli, Zstd, Identity} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			directive := WriteDirective{
+				Writer: bytes.NewReader([]byte(content)),
+				Codec:  codec,
+			}
+
+			blob, err := directive.Read()
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+
+			got, err := directive.Decode(blob, directive.CodecName())
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+
+			if string(got) != content {
+				t.Errorf("Decode round-trip = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+// TestWriteDirectiveCodecNameDefaultsToGzip mirrors Read's own default: a
+// directive with no Codec set must be readable as gzip.
+func TestWriteDirectiveCodecNameDefaultsToGzip(t *testing.T) {
+	directive := WriteDirective{Writer: bytes.NewReader([]byte("default"))}
+
+	if name := directive.CodecName(); name != Gzip.Name() {
+		t.Fatalf("CodecName() = %q, want %q", name, Gzip.Name())
+	}
+
+	blob, err := directive.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	got, err := directive.Decode(blob, directive.CodecName())
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if string(got) != "default" {
+		t.Errorf("Decode round-trip = %q, want %q", got, "default")
+	}
+}
+
+// TestCodecByNameUnknown ensures an unrecognized codec name surfaces as an
+// error instead of silently falling back to a codec the blob wasn't
+// compressed with.
+func TestCodecByNameUnknown(t *testing.T) {
+	if _, err := CodecByName("lz4"); err == nil {
+		t.Fatal("CodecByName(\"lz4\") = nil error, want error for unregistered codec")
+	}
+}
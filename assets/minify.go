@@ -0,0 +1,55 @@
+package assets
+
+import (
+	"bytes"
+	"path/filepath"
+
+	"github.com/tdewolff/minify"
+	"github.com/tdewolff/minify/css"
+	"github.com/tdewolff/minify/html"
+	"github.com/tdewolff/minify/js"
+	"github.com/tdewolff/minify/json"
+	"github.com/tdewolff/minify/svg"
+	"github.com/tdewolff/minify/xml"
+)
+
+// minifyMimes maps the extensions Resource.Minify knows how to handle to
+// the mime type tdewolff/minify registers its minifiers under.
+var minifyMimes = map[string]string{
+	".html": "text/html",
+	".htm":  "text/html",
+	".css":  "text/css",
+	".js":   "application/javascript",
+	".json": "application/json",
+	".svg":  "image/svg+xml",
+	".xml":  "text/xml",
+}
+
+// newMinifier builds an *minify.M with the handful of minifiers this
+// package supports registered against their mime types.
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/html", html.Minify)
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("application/javascript", js.Minify)
+	m.AddFunc("application/json", json.Minify)
+	m.AddFunc("image/svg+xml", svg.Minify)
+	m.AddFunc("text/xml", xml.Minify)
+	return m
+}
+
+// minifyBytes minifies src according to path's extension. Extensions with
+// no registered minifier are returned unchanged.
+func minifyBytes(path string, src []byte) ([]byte, error) {
+	mime, ok := minifyMimes[filepath.Ext(path)]
+	if !ok {
+		return src, nil
+	}
+
+	var out bytes.Buffer
+	if err := newMinifier().Minify(mime, &out, bytes.NewReader(src)); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,323 @@
+package assets
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lockfileName is the lockfile Webpack writes after AddMount pins a module
+// or git source, mirroring go.sum's "pin what you resolved to" role.
+const lockfileName = "gu.assets.lock"
+
+// mountCacheDir is where git mount sources are cloned and where the overlay
+// tree is built. Go module sources stay in the Go toolchain's module cache.
+const mountCacheDir = ".gu-cache/mounts"
+
+// MountOptions configures how a mounted source is resolved and overlaid.
+type MountOptions struct {
+	// Version pins a Go module or git ref (tag, branch or commit). Ignored
+	// for local paths.
+	Version string
+
+	// Subdir restricts the mount to a subdirectory of the resolved source,
+	// e.g. "scss/" inside a theme's module, so only part of it is overlaid.
+	Subdir string
+}
+
+// Mount describes one source directory overlaid into the asset tree, plus
+// the target path it is mounted at.
+type Mount struct {
+	Source  string
+	Target  string
+	Options MountOptions
+}
+
+// AddMount registers a source directory to be overlaid at target within the
+// virtual asset tree Build walks. source may be a local path, a Go module
+// path (resolved via `go mod download`), or a git URL. Mounts are applied
+// in registration order, with later mounts taking precedence over earlier
+// ones when paths collide, and are pinned into gu.assets.lock.
+func (w *Webpack) AddMount(source, target string, opts MountOptions) error {
+	resolved, version, err := resolveMountSource(source, opts)
+	if err != nil {
+		return fmt.Errorf("AddMount %q: %v", source, err)
+	}
+
+	w.mounts = append(w.mounts, Mount{Source: resolved, Target: target, Options: opts})
+
+	if version != "" {
+		if err := pinMount(source, version); err != nil {
+			return fmt.Errorf("AddMount %q: %v", source, err)
+		}
+	}
+
+	return nil
+}
+
+// overlayDir materializes all registered mounts on top of dir into a scratch
+// tree and returns its path. If no mounts are registered, dir is returned
+// unchanged so Build's walk behaves exactly as before mounts existed.
+func (w *Webpack) overlayDir(dir string) (string, error) {
+	if len(w.mounts) == 0 {
+		return dir, nil
+	}
+
+	overlay := filepath.Join(mountCacheDir, "overlay")
+	if err := os.RemoveAll(overlay); err != nil {
+		return "", err
+	}
+
+	if err := copyTree(dir, overlay); err != nil {
+		return "", err
+	}
+
+	for _, mount := range w.mounts {
+		src := mount.Source
+		if mount.Options.Subdir != "" {
+			src = filepath.Join(src, mount.Options.Subdir)
+		}
+
+		if err := copyTree(src, filepath.Join(overlay, mount.Target)); err != nil {
+			return "", err
+		}
+	}
+
+	return overlay, nil
+}
+
+// resolveMountSource fetches source (a git URL is cloned into mountCacheDir,
+// a Go module path is downloaded into the Go module cache) and returns the
+// local path to read from, along with
+// the resolved version string (empty for plain local paths).
+func resolveMountSource(source string, opts MountOptions) (string, string, error) {
+	switch {
+	case strings.HasPrefix(source, "git://") || strings.HasSuffix(source, ".git"):
+		dest := filepath.Join(mountCacheDir, "git", sanitizeMountKey(source))
+
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			if err := runGit("clone", source, dest); err != nil {
+				return "", "", err
+			}
+		}
+
+		if opts.Version != "" {
+			if err := runGitIn(dest, "checkout", opts.Version); err != nil {
+				return "", "", err
+			}
+		}
+
+		return dest, opts.Version, nil
+
+	case isGoModulePath(source):
+		version := opts.Version
+		if version == "" {
+			version = "latest"
+		}
+
+		cmd := exec.Command("go", "mod", "download", "-json", source+"@"+version)
+		if err := cmd.Run(); err != nil {
+			return "", "", fmt.Errorf("go mod download %s@%s: %v", source, version, err)
+		}
+
+		dir, err := goModuleDir(source, version)
+		if err != nil {
+			return "", "", err
+		}
+
+		return dir, version, nil
+
+	default:
+		return source, "", nil
+	}
+}
+
+// isGoModulePath is a light heuristic distinguishing "github.com/x/y"
+// module paths from plain local filesystem paths.
+func isGoModulePath(source string) bool {
+	if filepath.IsAbs(source) || strings.HasPrefix(source, ".") {
+		return false
+	}
+
+	return strings.Contains(source, "/") && strings.Contains(strings.SplitN(source, "/", 2)[0], ".")
+}
+
+// goModuleDir asks the Go toolchain where it cached a downloaded module.
+func goModuleDir(module, version string) (string, error) {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", module+"@"+version).Output()
+	if err != nil {
+		return "", fmt.Errorf("go list -m %s@%s: %v", module, version, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+func runGit(args ...string) error {
+	return exec.Command("git", args...).Run()
+}
+
+func runGitIn(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.Run()
+}
+
+// sanitizeMountKey turns a source URL into a filesystem-safe directory name.
+func sanitizeMountKey(source string) string {
+	replacer := strings.NewReplacer("/", "-", ":", "-", "@", "-")
+	return replacer.Replace(source)
+}
+
+// copyTree recursively copies src into dst, creating dst if needed. It is
+// used to build the overlay tree out of local mounts without symlinking,
+// since mounted git/module sources may be read-only caches.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		// Keep copies owner-writable so a later mount can overwrite a file
+		// copied from a read-only module cache.
+		return ioutil.WriteFile(target, data, info.Mode()|0200)
+	})
+}
+
+// pinMount records source's resolved version in gu.assets.lock, keeping the
+// file sorted by source and applying minimal version selection: if source
+// is already pinned, the higher of the two versions wins.
+func pinMount(source, version string) error {
+	entries, err := readLockfile()
+	if err != nil {
+		return err
+	}
+
+	if existing, ok := entries[source]; ok {
+		if selectHigherVersion(existing, version) == existing {
+			return nil
+		}
+	}
+
+	entries[source] = version
+
+	return writeLockfile(entries)
+}
+
+// selectHigherVersion implements minimal version selection between two
+// semver-ish version strings: the numerically higher dotted-numeric version
+// wins, with "latest" always losing to an explicit version.
+func selectHigherVersion(a, b string) string {
+	if a == "latest" {
+		return b
+	}
+
+	if b == "latest" {
+		return a
+	}
+
+	if compareVersions(a, b) >= 0 {
+		return a
+	}
+
+	return b
+}
+
+// compareVersions compares two dotted version strings (e.g. "v1.2.3")
+// numerically component by component, returning <0, 0 or >0.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv int
+
+		if i < len(as) {
+			fmt.Sscanf(as[i], "%d", &av)
+		}
+
+		if i < len(bs) {
+			fmt.Sscanf(bs[i], "%d", &bv)
+		}
+
+		if av != bv {
+			return av - bv
+		}
+	}
+
+	return 0
+}
+
+// readLockfile parses gu.assets.lock into a source -> version map. A
+// missing lockfile is treated as empty rather than an error.
+func readLockfile() (map[string]string, error) {
+	entries := make(map[string]string)
+
+	f, err := os.Open(lockfileName)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		entries[parts[0]] = parts[1]
+	}
+
+	return entries, scanner.Err()
+}
+
+// writeLockfile writes entries back to gu.assets.lock, sorted by source so
+// diffs stay minimal across runs.
+func writeLockfile(entries map[string]string) error {
+	sources := make([]string, 0, len(entries))
+	for source := range entries {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+
+	var lines []string
+	for _, source := range sources {
+		lines = append(lines, fmt.Sprintf("%s %s", source, entries[source]))
+	}
+
+	return ioutil.WriteFile(lockfileName, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,39 @@
+package assets
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+// countingPacker tracks how many times Pack was invoked, so tests can assert
+// no Packer ever ran when packExts should have bailed out before launching
+// any goroutine.
+type countingPacker struct {
+	calls int32
+}
+
+func (p *countingPacker) Pack(ctx context.Context, files []FileStatement, dir DirStatement) ([]WriteDirective, error) {
+	atomic.AddInt32(&p.calls, 1)
+	return nil, nil
+}
+
+// TestPackExtsMissingPackerRunsNoPackers ensures an extension with no
+// registered Packer (and no defaultPacker) is caught before any other
+// extension's Packer is launched, rather than after, so a missing-packer
+// error never leaves sibling goroutines running unawaited.
+func TestPackExtsMissingPackerRunsNoPackers(t *testing.T) {
+	packer := &countingPacker{}
+
+	w := New(nil)
+	w.Register(".css", packer)
+
+	_, err := w.packExts(context.Background(), DirStatement{}, []string{".css", ".missing"})
+	if err == nil {
+		t.Fatal("packExts() error = nil, want error for extension with no Packer")
+	}
+
+	if calls := atomic.LoadInt32(&packer.calls); calls != 0 {
+		t.Errorf("packer.calls = %d, want 0; packExts must validate all extensions before packing any", calls)
+	}
+}
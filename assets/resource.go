@@ -0,0 +1,346 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// execTimeout bounds how long an external transform tool (dart-sass, npx
+// postcss, ...) is allowed to run before it is killed.
+const execTimeout = 30 * time.Second
+
+// cacheDir is where transform output is memoized, relative to the process's
+// working directory. It mirrors Hugo Pipes' "resources/_gen".
+const cacheDir = "resources/_gen"
+
+// ToCSSOptions controls how a Sass/SCSS resource is transpiled to CSS.
+type ToCSSOptions struct {
+	// TargetPath overrides the generated resource's path. Defaults to the
+	// source path with its extension swapped for ".css".
+	TargetPath string
+
+	// EnableSourceMap asks the underlying compiler to emit an inline source map.
+	EnableSourceMap bool
+
+	// IncludePaths are additional directories passed to the Sass compiler
+	// for `@import` resolution.
+	IncludePaths []string
+}
+
+// PostCSSOptions controls how a Resource is run through PostCSS.
+type PostCSSOptions struct {
+	// Config points at a postcss.config.js; when empty, postcss picks up
+	// whatever config it finds alongside the source.
+	Config string
+
+	// NoMap disables source map generation.
+	NoMap bool
+}
+
+// Resource defines a handle to a single asset as it moves through a chain of
+// transforms (ToCSS, PostCSS, Minify, Fingerprint) before being handed off
+// to a Packer. Each transform returns a new Resource, so calls may be
+// chained; an error encountered partway through the chain is carried along
+// and surfaces from Content.
+type Resource interface {
+	// Path returns the resource's current logical path, e.g. "styles.css"
+	// after a ToCSS transform on "styles.scss".
+	Path() string
+
+	// Content returns the resource's current bytes, running the transform
+	// chain if it has not been materialized yet.
+	Content() ([]byte, error)
+
+	// Integrity returns the SRI digest (e.g. "sha256-...") set by
+	// Fingerprint. It is empty until Fingerprint has run.
+	Integrity() string
+
+	// ToCSS compiles a Sass/SCSS resource to CSS.
+	ToCSS(opts ToCSSOptions) Resource
+
+	// PostCSS pipes the resource's current content through `npx postcss`.
+	PostCSS(opts PostCSSOptions) Resource
+
+	// Minify shrinks the resource using tdewolff/minify, picking the
+	// minifier by the resource's current extension.
+	Minify() Resource
+
+	// Fingerprint content-hashes the resource into its filename and
+	// records an SRI integrity digest.
+	Fingerprint() Resource
+
+	// Directive materializes the resource (running its transform chain) and
+	// returns it as a WriteDirective ready for Webpack.Build/Compile, so a
+	// Packer can emit Resource handles and feed them into this pipeline
+	// before they become part of the generated output. The directive's
+	// Integrity is populated from the resource's SRI digest, if Fingerprint
+	// has run.
+	Directive() (WriteDirective, error)
+}
+
+// resource is the default Resource implementation. ToCSS, PostCSS and Minify
+// are applied lazily the first time Content is called and memoized under
+// cacheDir; Fingerprint reads its input's content as soon as it is called.
+type resource struct {
+	webpack *Webpack
+	path    string
+	load    func() ([]byte, error)
+	err     error
+
+	content   []byte
+	loaded    bool
+	integrity string
+}
+
+// Get resolves path to a Resource. path is read as-is (relative to the
+// process's working directory, or absolute); Webpack stores no base asset
+// directory of its own to resolve it against. The returned Resource reads
+// lazily; no file I/O happens until a transform or Content is invoked.
+func (w *Webpack) Get(path string) Resource {
+	return &resource{
+		webpack: w,
+		path:    path,
+		load: func() ([]byte, error) {
+			return ioutil.ReadFile(path)
+		},
+	}
+}
+
+// Path returns the resource's current logical path.
+func (r *resource) Path() string {
+	return r.path
+}
+
+// Integrity returns the SRI digest set by Fingerprint, or "" if unset.
+func (r *resource) Integrity() string {
+	return r.integrity
+}
+
+// Content materializes the resource, running its transform chain once and
+// caching the result for subsequent calls.
+func (r *resource) Content() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if r.loaded {
+		return r.content, nil
+	}
+
+	content, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	r.content = content
+	r.loaded = true
+	return r.content, nil
+}
+
+// chain returns a derived Resource for the given target path whose content
+// is produced by transform, checking the on-disk cache first and writing
+// into it afterwards. keyPrefix should identify the options feeding
+// transform; the source content's bytes are folded in automatically so
+// editing an input invalidates the cache even when keyPrefix is unchanged.
+func (r *resource) chain(targetPath string, keyPrefix string, transform func([]byte) ([]byte, error)) Resource {
+	if r.err != nil {
+		return r
+	}
+
+	next := &resource{
+		webpack: r.webpack,
+		path:    targetPath,
+		load: func() ([]byte, error) {
+			src, err := r.Content()
+			if err != nil {
+				return nil, err
+			}
+
+			cachePath := filepath.Join(cacheDir, contentCacheKey(keyPrefix, src)+"-"+filepath.Base(targetPath))
+
+			if cached, err := ioutil.ReadFile(cachePath); err == nil {
+				return cached, nil
+			}
+
+			out, err := transform(src)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+				_ = ioutil.WriteFile(cachePath, out, 0644)
+			}
+
+			return out, nil
+		},
+	}
+
+	return next
+}
+
+// ToCSS compiles the resource with dart-sass (falling back to sassc/libsass
+// if dart-sass is not on PATH) and returns a Resource at targetPath.
+func (r *resource) ToCSS(opts ToCSSOptions) Resource {
+	target := opts.TargetPath
+	if target == "" {
+		target = swapExt(r.path, ".css")
+	}
+
+	return r.chain(target, fmt.Sprintf("tocss:%v:%v", opts.EnableSourceMap, opts.IncludePaths), func(src []byte) ([]byte, error) {
+		return runSassCompiler(src, opts)
+	})
+}
+
+// PostCSS pipes the resource through `npx postcss`, honoring opts.Config.
+func (r *resource) PostCSS(opts PostCSSOptions) Resource {
+	return r.chain(r.path, fmt.Sprintf("postcss:%s:%v", opts.Config, opts.NoMap), func(src []byte) ([]byte, error) {
+		return runPostCSS(src, opts)
+	})
+}
+
+// Minify shrinks the resource's current content using its file extension to
+// pick the right minifier (html, css, js, json or svg/xml).
+func (r *resource) Minify() Resource {
+	return r.chain(r.path, "minify", func(src []byte) ([]byte, error) {
+		return minifyBytes(r.path, src)
+	})
+}
+
+// Fingerprint content-hashes the resource into its filename (e.g.
+// "styles.min.css" -> "styles.min.a1b2c3d4e5f60718.css", using the first 16
+// hex characters of its SHA-256) and records an SRI digest
+// on the returned Resource.
+func (r *resource) Fingerprint() Resource {
+	if r.err != nil {
+		return r
+	}
+
+	src, err := r.Content()
+	if err != nil {
+		return &resource{err: err}
+	}
+
+	sum := sha256.Sum256(src)
+	hash := hex.EncodeToString(sum[:])[:16]
+
+	ext := filepath.Ext(r.path)
+	base := r.path[:len(r.path)-len(ext)]
+
+	return &resource{
+		webpack:   r.webpack,
+		path:      fmt.Sprintf("%s.%s%s", base, hash, ext),
+		content:   src,
+		loaded:    true,
+		integrity: "sha256-" + base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+// Directive materializes the resource and returns it as a WriteDirective,
+// carrying the resource's path and (if Fingerprint has run) its SRI
+// Integrity digest through to Webpack.Build/Compile.
+func (r *resource) Directive() (WriteDirective, error) {
+	content, err := r.Content()
+	if err != nil {
+		return WriteDirective{}, err
+	}
+
+	return WriteDirective{
+		Writer:     bytes.NewReader(content),
+		OriginPath: r.path,
+		Integrity:  r.integrity,
+	}, nil
+}
+
+// contentCacheKey reduces keyPrefix (the transform's options) together with
+// the source content being fed into the transform to a short hex digest, so
+// the cache is invalidated whenever either the options or the input bytes
+// change.
+func contentCacheKey(keyPrefix string, content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(keyPrefix))
+	h.Write([]byte{0})
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// swapExt replaces path's extension with ext.
+func swapExt(path, ext string) string {
+	return path[:len(path)-len(filepath.Ext(path))] + ext
+}
+
+// runSassCompiler shells out to dart-sass (preferring it for SCSS/Sass
+// compatibility), falling back to sassc (libsass) if dart-sass isn't on
+// PATH, with a bounded timeout, feeding src over stdin.
+func runSassCompiler(src []byte, opts ToCSSOptions) ([]byte, error) {
+	if _, err := exec.LookPath("dart-sass"); err == nil {
+		args := []string{"--stdin", "--indented=false"}
+		if opts.EnableSourceMap {
+			args = append(args, "--source-map")
+		} else {
+			args = append(args, "--no-source-map")
+		}
+
+		for _, inc := range opts.IncludePaths {
+			args = append(args, "--load-path="+inc)
+		}
+
+		return runSandboxed("dart-sass", args, src)
+	}
+
+	args := []string{"--stdin"}
+	if opts.EnableSourceMap {
+		args = append(args, "-m")
+	}
+
+	for _, inc := range opts.IncludePaths {
+		args = append(args, "-I", inc)
+	}
+
+	return runSandboxed("sassc", args, src)
+}
+
+// runPostCSS shells out to `npx postcss`, applying opts.Config if set.
+func runPostCSS(src []byte, opts PostCSSOptions) ([]byte, error) {
+	args := []string{"postcss"}
+	if opts.Config != "" {
+		args = append(args, "--config", opts.Config)
+	}
+
+	if opts.NoMap {
+		args = append(args, "--no-map")
+	}
+
+	return runSandboxed("npx", args, src)
+}
+
+// runSandboxed executes name with args inside a timeout-bound context,
+// feeding in over stdin and returning stdout. It is the small exec wrapper
+// every exec-backed transform in this file funnels through, keeping the
+// sandboxing (timeout, no shell, captured stderr) in one place.
+func runSandboxed(name string, args []string, in []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(in)
+
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%s: %s", name, string(exitErr.Stderr))
+		}
+
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,236 @@
+package assets
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+// defaultDebounce is how long Serve waits after the last filesystem event
+// in a burst before re-packing, giving editors time to finish writing a
+// file (many write in multiple syscalls).
+const defaultDebounce = 200 * time.Millisecond
+
+// ServeOptions configures Webpack.Serve's dev-mode asset server.
+type ServeOptions struct {
+	// Dir is the asset directory to watch and rebuild, as passed to Build.
+	Dir string
+
+	// DoGoSources mirrors Build's doGoSources argument.
+	DoGoSources bool
+
+	// Debounce is how long to wait after the last change in a burst before
+	// rebuilding. Defaults to 200ms.
+	Debounce time.Duration
+}
+
+// Serve starts a filesystem watcher over opts.Dir and an HTTP server at addr
+// exposing a "/livereload" websocket endpoint. Changed files are repacked
+// through the matching Packer; CSS-only changes push a css-patch message so
+// connected browsers can swap stylesheets in place, everything else falls
+// back to a full-page reload.
+func (w *Webpack) Serve(addr string, opts ServeOptions) error {
+	if opts.Debounce <= 0 {
+		opts.Debounce = defaultDebounce
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := addWatchRecursive(watcher, opts.Dir); err != nil {
+		return err
+	}
+
+	hub := newReloadHub()
+
+	mux := http.NewServeMux()
+	mux.Handle("/livereload", hub)
+
+	go w.watchLoop(watcher, opts, hub)
+
+	return http.ListenAndServe(addr, mux)
+}
+
+// watchLoop debounces raw fsnotify events into batches, rebuilds the
+// Webpack, and notifies hub of what kind of reload connected browsers need.
+func (w *Webpack) watchLoop(watcher *fsnotify.Watcher, opts ServeOptions, hub *reloadHub) {
+	var (
+		timer   *time.Timer
+		pending = make(map[string]struct{})
+		mu      sync.Mutex
+	)
+
+	flush := func() {
+		mu.Lock()
+		paths := make([]string, 0, len(pending))
+		for p := range pending {
+			paths = append(paths, p)
+		}
+		pending = make(map[string]struct{})
+		mu.Unlock()
+
+		if len(paths) == 0 {
+			return
+		}
+
+		if _, err := w.Rebuild(opts.Dir, opts.DoGoSources, changedExts(paths)); err != nil {
+			log.Printf("assets: rebuild failed: %v", err)
+			return
+		}
+
+		if allCSS(paths) {
+			hub.broadcast(reloadMessage{Type: "css", Paths: paths})
+		} else {
+			hub.broadcast(reloadMessage{Type: "reload"})
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			mu.Lock()
+			pending[event.Name] = struct{}{}
+			mu.Unlock()
+
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(opts.Debounce, flush)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Printf("assets: watcher error: %v", err)
+		}
+	}
+}
+
+// addWatchRecursive adds dir and every subdirectory beneath it to watcher,
+// since fsnotify watches are not recursive on their own.
+func addWatchRecursive(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+
+		return nil
+	})
+}
+
+// changedExts returns the set of distinct file extensions among paths, in
+// the ".ext" form Webpack.packExts keys its Packers by, so a burst of
+// changes only repacks the extensions actually touched.
+func changedExts(paths []string) []string {
+	seen := make(map[string]struct{}, len(paths))
+	exts := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		ext := strings.ToLower(filepath.Ext(p))
+		if _, ok := seen[ext]; ok {
+			continue
+		}
+
+		seen[ext] = struct{}{}
+		exts = append(exts, ext)
+	}
+
+	return exts
+}
+
+// allCSS reports whether every path in paths is a .css, .scss or .sass file.
+func allCSS(paths []string) bool {
+	for _, p := range paths {
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".css" && ext != ".scss" && ext != ".sass" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reloadMessage is broadcast over the /livereload websocket as JSON.
+type reloadMessage struct {
+	Type  string   `json:"type"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+// reloadHub tracks connected /livereload websocket clients and broadcasts
+// reload messages to all of them.
+type reloadHub struct {
+	upgrader websocket.Upgrader
+	mu       sync.Mutex
+	clients  map[*websocket.Conn]struct{}
+}
+
+// newReloadHub returns a ready-to-use reloadHub.
+func newReloadHub() *reloadHub {
+	return &reloadHub{
+		clients: make(map[*websocket.Conn]struct{}),
+	}
+}
+
+// ServeHTTP upgrades the request to a websocket connection and registers it
+// for future broadcasts, removing it once the connection closes.
+func (h *reloadHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("assets: livereload upgrade failed: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	h.clients[conn] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, conn)
+		h.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// broadcast sends msg to every connected client, dropping clients that fail
+// to receive it.
+func (h *reloadHub) broadcast(msg reloadMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for conn := range h.clients {
+		if err := conn.WriteJSON(msg); err != nil {
+			conn.Close()
+			delete(h.clients, conn)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package css
 
 import (
+	"bufio"
 	"bytes"
+	"io/ioutil"
+	"regexp"
 	"strings"
 	"text/template"
 
 	bcss "github.com/aymerick/douceur/css"
 	"github.com/aymerick/douceur/parser"
+	"github.com/bmatcuk/doublestar"
 )
 
 var (
@@ -83,14 +87,14 @@ func (r *Rule) extend(item string) string {
 
 // Stylesheet returns the provided styles using the binding as the argument for the
 // provided css template.
-func (r *Rule) Stylesheet(bind interface{}, parentNode string) (*bcss.Stylesheet, error) {
+func (r *Rule) Stylesheet(bind interface{}, parentNode string) (*Stylesheet, error) {
 	if r.feed != nil {
 		sheet, err := r.feed.Stylesheet(bind, parentNode)
 		if err != nil {
 			return nil, err
 		}
 
-		r.feedStyle = sheet
+		r.feedStyle = sheet.Stylesheet
 	}
 
 	var stylesheet bcss.Stylesheet
@@ -122,7 +126,7 @@ func (r *Rule) Stylesheet(bind interface{}, parentNode string) (*bcss.Stylesheet
 
 	stylesheet.Rules = append(stylesheet.Rules, sheet.Rules...)
 
-	return &stylesheet, nil
+	return &Stylesheet{&stylesheet}, nil
 }
 
 // adjustName adjust the provided name according to the set rules of for specific
@@ -159,3 +163,172 @@ func (r *Rule) morphRule(base *bcss.Rule, parentNode string) {
 		}
 	}
 }
+
+// Stylesheet wraps a *bcss.Stylesheet to attach gu-specific post-processing
+// such as Purge, while still exposing the underlying douceur fields (Rules,
+// ErrorString, etc) through embedding.
+type Stylesheet struct {
+	*bcss.Stylesheet
+}
+
+// PurgeConfig controls Stylesheet.Purge's content-aware pruning of unused
+// rules, Tailwind/PurgeCSS-style.
+type PurgeConfig struct {
+	// Content is a list of doublestar globs (e.g. "**/*.html", "**/*.go")
+	// scanned for class/id tokens that are considered "used".
+	Content []string
+
+	// Safelist are regexps matched against each rule's selectors; any
+	// selector matching one is kept regardless of whether it was found in
+	// Content.
+	Safelist []*regexp.Regexp
+}
+
+// classTokenPattern extracts identifier-like runs (ASCII letters/digits plus
+// "-_:/") from content files, which is where class and id names live in
+// HTML/JSX/Go template source.
+var classTokenPattern = regexp.MustCompile(`[A-Za-z0-9_:/-]+`)
+
+// Purge drops rules from the stylesheet whose selectors reference no class
+// or id token found in cfg.Content, shrinking generated CSS for projects
+// that bundle utility frameworks. @keyframes and @font-face at-rules are
+// always preserved, as is any selector matched by cfg.Safelist. At-rules
+// that embed further rules (e.g. @media) are recursed into rather than
+// pruned outright, so responsive breakpoints survive with only their
+// unused nested rules dropped.
+func (s *Stylesheet) Purge(cfg PurgeConfig) (*Stylesheet, error) {
+	used, err := scanUsedTokens(cfg.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Rules = purgeRules(s.Rules, used, cfg.Safelist)
+	return s, nil
+}
+
+// alwaysKeptAtRule reports whether rule is an at-rule whose contents must be
+// preserved verbatim regardless of selector usage, keyed on the at-keyword
+// douceur stores in rule.Name (e.g. "@keyframes", "@font-face").
+func alwaysKeptAtRule(rule *bcss.Rule) bool {
+	name := strings.TrimPrefix(rule.Name, "@")
+	return strings.HasSuffix(name, "keyframes") || name == "font-face"
+}
+
+// purgeRules filters a slice of rules, recursing into at-rules that embed
+// further rules (such as @media) instead of pruning them by their own
+// (empty) Selectors.
+func purgeRules(rules []*bcss.Rule, used map[string]struct{}, safelist []*regexp.Regexp) []*bcss.Rule {
+	var kept []*bcss.Rule
+
+	for _, rule := range rules {
+		if rule.Kind == bcss.AtRule {
+			if alwaysKeptAtRule(rule) {
+				kept = append(kept, rule)
+				continue
+			}
+
+			if rule.EmbedsRules() {
+				rule.Rules = purgeRules(rule.Rules, used, safelist)
+				if len(rule.Rules) == 0 {
+					continue
+				}
+
+				kept = append(kept, rule)
+				continue
+			}
+
+			// A non-embedding at-rule (@import, @charset, @namespace, ...)
+			// has no Selectors of its own to test for usage; it isn't a
+			// "rule with zero matching selectors", it's a directive that
+			// must be kept verbatim or the stylesheet breaks.
+			kept = append(kept, rule)
+			continue
+		}
+
+		var selectors []string
+		for _, sel := range rule.Selectors {
+			if selectorIsUsed(sel, used, safelist) {
+				selectors = append(selectors, sel)
+			}
+		}
+
+		if len(selectors) == 0 {
+			continue
+		}
+
+		rule.Selectors = selectors
+		kept = append(kept, rule)
+	}
+
+	return kept
+}
+
+// scanUsedTokens reads every file matched by the given doublestar globs and
+// collects the set of identifier-like tokens found in them.
+func scanUsedTokens(globs []string) (map[string]struct{}, error) {
+	used := make(map[string]struct{})
+
+	for _, pattern := range globs {
+		matches, err := doublestar.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range matches {
+			if err := scanFileTokens(path, used); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return used, nil
+}
+
+// scanFileTokens extracts class/id-like tokens from a single content file
+// into used.
+func scanFileTokens(path string, used map[string]struct{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		for _, tok := range classTokenPattern.FindAllString(scanner.Text(), -1) {
+			used[tok] = struct{}{}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// selectorClassIDPattern extracts ".class" and "#id" tokens from a selector.
+// Pseudo-class/pseudo-element suffixes (".btn:hover") are not part of the
+// match, so states don't need to appear verbatim in content.
+var selectorClassIDPattern = regexp.MustCompile(`[.#][A-Za-z0-9_-]+`)
+
+// selectorIsUsed reports whether sel references at least one class or id
+// token in used, or matches one of the safelist regexps. Selectors with no
+// class or id token (e.g. "*", "body") are always kept.
+func selectorIsUsed(sel string, used map[string]struct{}, safelist []*regexp.Regexp) bool {
+	for _, re := range safelist {
+		if re.MatchString(sel) {
+			return true
+		}
+	}
+
+	tokens := selectorClassIDPattern.FindAllString(sel, -1)
+	if len(tokens) == 0 {
+		return true
+	}
+
+	for _, tok := range tokens {
+		if _, ok := used[tok[1:]]; ok {
+			return true
+		}
+	}
+
+	return false
+}
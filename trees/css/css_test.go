@@ -0,0 +1,103 @@
+package css
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/aymerick/douceur/parser"
+)
+
+// TestPurge covers the cases that are easy to get wrong: unused rules
+// dropping, @keyframes/@font-face surviving untouched, @media keeping its
+// embedded rules filtered rather than dropped wholesale, non-embedding
+// at-rules (@import, @charset) surviving since they have nothing to match
+// against a content scan, and the safelist overriding usage.
+func TestPurge(t *testing.T) {
+	const src = `
+@import url("fonts.css");
+@charset "UTF-8";
+@keyframes spin {
+  from { transform: rotate(0deg); }
+  to { transform: rotate(360deg); }
+}
+.used { color: red; }
+.unused { color: blue; }
+.kept-by-safelist { color: yellow; }
+@media (min-width: 600px) {
+  .used { color: green; }
+  .unused { color: black; }
+}
+`
+
+	sheet, err := parser.Parse(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(`<div class="used"></div>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := (&Stylesheet{sheet}).Purge(PurgeConfig{
+		Content:  []string{filepath.Join(dir, "*.html")},
+		Safelist: []*regexp.Regexp{regexp.MustCompile(`kept-by-safelist`)},
+	})
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	var sawImport, sawCharset, sawKeyframes, sawMedia bool
+
+	for _, rule := range purged.Rules {
+		switch rule.Name {
+		case "@import":
+			sawImport = true
+		case "@charset":
+			sawCharset = true
+		case "@keyframes":
+			sawKeyframes = true
+			if len(rule.Rules) != 2 {
+				t.Errorf("@keyframes rules = %d, want 2 (untouched)", len(rule.Rules))
+			}
+		case "@media":
+			sawMedia = true
+
+			var mediaSelectors []string
+			for _, nested := range rule.Rules {
+				mediaSelectors = append(mediaSelectors, nested.Selectors...)
+			}
+
+			if len(mediaSelectors) != 1 || mediaSelectors[0] != ".used" {
+				t.Errorf("@media nested selectors = %v, want [.used]", mediaSelectors)
+			}
+		default:
+			if len(rule.Selectors) != 1 {
+				t.Fatalf("unexpected top-level rule: %+v", rule)
+			}
+
+			switch rule.Selectors[0] {
+			case ".used", ".kept-by-safelist":
+			case ".unused":
+				t.Errorf(".unused rule should have been purged")
+			default:
+				t.Errorf("unexpected selector %q survived Purge", rule.Selectors[0])
+			}
+		}
+	}
+
+	if !sawImport {
+		t.Error("@import was dropped, should be preserved")
+	}
+	if !sawCharset {
+		t.Error("@charset was dropped, should be preserved")
+	}
+	if !sawKeyframes {
+		t.Error("@keyframes was dropped, should be preserved")
+	}
+	if !sawMedia {
+		t.Error("@media was dropped, should be preserved with filtered nested rules")
+	}
+}